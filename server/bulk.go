@@ -0,0 +1,198 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"go-rest/server/context"
+)
+
+// bulkURISuffix is appended to a resource's default list URI to form its bulk
+// endpoint, e.g. "/api/{version}/widgets/_bulk".
+const bulkURISuffix = "/_bulk"
+
+// defaultBulkURI returns the conventional route template for a resource's bulk
+// endpoint, used to register "POST .../_bulk" alongside the resource's other
+// routes.
+func defaultBulkURI(resourceName string) string {
+	return defaultURI(resourceName, false) + bulkURISuffix
+}
+
+// bulkOp identifies the operation requested for a single item in a bulk request.
+type bulkOp string
+
+const (
+	bulkCreate bulkOp = "create"
+	bulkUpdate bulkOp = "update"
+	bulkDelete bulkOp = "delete"
+)
+
+// bulkRequestItem is a single entry in a "_bulk" request body.
+type bulkRequestItem struct {
+	Op   bulkOp  `json:"op"`
+	ID   string  `json:"id,omitempty"`
+	Data Payload `json:"data,omitempty"`
+}
+
+// bulkRequest is the body accepted by a resource's "_bulk" endpoint. When Atomic is
+// true, every item must succeed or none are applied (see TransactionalResourceHandler);
+// otherwise items are processed independently and partial success is reported.
+type bulkRequest struct {
+	Atomic bool              `json:"atomic,omitempty"`
+	Items  []bulkRequestItem `json:"items"`
+}
+
+// bulkResultItem reports the outcome of one bulkRequestItem.
+type bulkResultItem struct {
+	Status int      `json:"status"`
+	Result Resource `json:"result,omitempty"`
+	Error  string   `json:"error,omitempty"`
+}
+
+// decodeBulkRequest converts raw, the generic map a ResponseSerializer decoded the
+// request body into, to a bulkRequest. ResponseSerializer.decode only knows how to
+// target map[string]interface{}, so the bulkRequest shape is recovered by
+// round-tripping raw through encoding/json. This recovers the shape only when raw
+// holds JSON-compatible values of the expected kinds: it returns an error if a
+// value can't be marshalled as JSON (e.g. a map with non-string keys), or if a
+// field has the wrong kind, such as the string "true" that xmlSerializer.decode
+// yields for "atomic", or a single XML "items" element decoded as a map rather
+// than a list.
+func decodeBulkRequest(raw map[string]interface{}) (bulkRequest, error) {
+	var req bulkRequest
+	data, err := json.Marshal(raw)
+	if err != nil {
+		return req, err
+	}
+	if err := json.Unmarshal(data, &req); err != nil {
+		return req, err
+	}
+	return req, nil
+}
+
+// TransactionalResourceHandler is implemented by ResourceHandlers that can honor
+// the "atomic" flag on a bulk request. WithTransaction invokes fn, which performs
+// the bulk request's CreateResource/UpdateResource/DeleteResource calls, and must
+// roll back every change those calls made if fn returns an error.
+type TransactionalResourceHandler interface {
+	WithTransaction(fn func() error) error
+}
+
+// handleBulk returns a HandlerFunc which decodes a "_bulk" request body and
+// dispatches each item to handler's CreateResource, UpdateResource, or
+// DeleteResource according to its "op", collecting a per-item result. If the
+// request sets "atomic", handler must implement TransactionalResourceHandler so the
+// items can be run within a single transaction; otherwise items are processed
+// independently. The serialization mechanism used is negotiated from the request
+// (see sendResponse). If metrics are enabled, the request is instrumented under
+// resourceName.
+func (h requestHandler) handleBulk(resourceName string, links resourceLinks,
+	handler ResourceHandler) http.HandlerFunc {
+	return h.instrument(resourceName, "POST", func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := h.newContext(r)
+		defer cancel()
+
+		var raw map[string]interface{}
+		decoder := h.requestSerializer(r.Header.Get("Content-Type"))
+		if err := decoder.decode(r.Body, &raw); err != nil {
+			ctx = ctx.SetError(err)
+			ctx = ctx.SetStatus(http.StatusInternalServerError)
+			h.sendResponse(w, r, ctx, links)
+			return
+		}
+
+		req, err := decodeBulkRequest(raw)
+		if err != nil {
+			ctx = ctx.SetError(err)
+			ctx = ctx.SetStatus(http.StatusBadRequest)
+			h.sendResponse(w, r, ctx, links)
+			return
+		}
+
+		version := ctx.Version()
+		apply := func() []bulkResultItem {
+			results := make([]bulkResultItem, len(req.Items))
+			for i, item := range req.Items {
+				results[i] = h.applyBulkItem(ctx, handler, version, item)
+			}
+			return results
+		}
+
+		var results []bulkResultItem
+		status := http.StatusOK
+		if req.Atomic {
+			txHandler, ok := handler.(TransactionalResourceHandler)
+			if !ok {
+				ctx = ctx.SetError(fmt.Errorf("%s does not support atomic bulk operations", resourceName))
+				ctx = ctx.SetStatus(http.StatusNotImplemented)
+				h.sendResponse(w, r, ctx, links)
+				return
+			}
+
+			err := txHandler.WithTransaction(func() error {
+				results = apply()
+				for _, result := range results {
+					if result.Error != "" {
+						return fmt.Errorf("bulk operation failed: %s", result.Error)
+					}
+				}
+				return nil
+			})
+			if err != nil {
+				status = http.StatusConflict
+				// apply() already recorded the optimistic per-item outcomes before
+				// WithTransaction rolled them back; none of that was actually
+				// persisted, so replace every item that didn't itself fail with a
+				// result reflecting the rollback instead of shipping fabricated
+				// successes to the client.
+				for i, result := range results {
+					if result.Error == "" {
+						results[i] = bulkResultItem{Status: http.StatusConflict, Error: "rolled back: " + err.Error()}
+					}
+				}
+			}
+		} else {
+			results = apply()
+		}
+
+		ctx = ctx.SetResult(results)
+		ctx = ctx.SetStatus(status)
+		h.sendResponse(w, r, ctx, links)
+	})
+}
+
+// applyBulkItem dispatches a single bulkRequestItem to the corresponding
+// ResourceHandler method and reports its outcome, escalating the item's status to
+// 500 if the operation failed without setting its own error status.
+func (h requestHandler) applyBulkItem(ctx context.RequestContext, handler ResourceHandler,
+	version string, item bulkRequestItem) bulkResultItem {
+	var (
+		resource Resource
+		status   int
+		err      error
+	)
+
+	switch item.Op {
+	case bulkCreate:
+		resource, err = handler.CreateResource(ctx, item.Data, version)
+		status = http.StatusCreated
+	case bulkUpdate:
+		resource, err = handler.UpdateResource(ctx, item.ID, item.Data, version)
+		status = http.StatusOK
+	case bulkDelete:
+		resource, err = handler.DeleteResource(ctx, item.ID, version)
+		status = http.StatusOK
+	default:
+		err = fmt.Errorf("unsupported bulk op %q", item.Op)
+		status = http.StatusBadRequest
+	}
+
+	if err != nil {
+		if status < 400 {
+			status = http.StatusInternalServerError
+		}
+		return bulkResultItem{Status: status, Error: err.Error()}
+	}
+	return bulkResultItem{Status: status, Result: resource}
+}
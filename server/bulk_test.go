@@ -0,0 +1,126 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"go-rest/server/context"
+)
+
+func TestDecodeBulkRequest(t *testing.T) {
+	raw := map[string]interface{}{
+		"atomic": true,
+		"items": []interface{}{
+			map[string]interface{}{"op": "create", "data": map[string]interface{}{"name": "widget"}},
+			map[string]interface{}{"op": "delete", "id": "42"},
+		},
+	}
+
+	req, err := decodeBulkRequest(raw)
+	if err != nil {
+		t.Fatalf("decodeBulkRequest() err = %v, want nil", err)
+	}
+	if !req.Atomic {
+		t.Error("req.Atomic = false, want true")
+	}
+	if len(req.Items) != 2 {
+		t.Fatalf("len(req.Items) = %d, want 2", len(req.Items))
+	}
+	if req.Items[0].Op != bulkCreate || req.Items[0].Data["name"] != "widget" {
+		t.Errorf("req.Items[0] = %+v, want op=create data.name=widget", req.Items[0])
+	}
+	if req.Items[1].Op != bulkDelete || req.Items[1].ID != "42" {
+		t.Errorf("req.Items[1] = %+v, want op=delete id=42", req.Items[1])
+	}
+}
+
+func TestApplyBulkItemUnsupportedOp(t *testing.T) {
+	h := requestHandler{RestApi: &RestApi{}}
+	ctx := context.NewContext(nil, httptest.NewRequest("POST", "/api/v1/widgets/_bulk", nil))
+
+	result := h.applyBulkItem(ctx, BaseResourceHandler{}, "1", bulkRequestItem{Op: "frobnicate"})
+	if result.Status != 400 {
+		t.Errorf("result.Status = %d, want 400", result.Status)
+	}
+	if result.Error == "" {
+		t.Error("result.Error = \"\", want a message describing the unsupported op")
+	}
+}
+
+// txResourceHandler implements TransactionalResourceHandler and fails the create
+// at failOn (0-indexed among its CreateResource calls), simulating a handler that
+// successfully creates some items before hitting one it can't.
+type txResourceHandler struct {
+	BaseResourceHandler
+	failOn int
+	calls  int
+}
+
+func (h *txResourceHandler) ResourceName() string { return "widgets" }
+
+func (h *txResourceHandler) CreateResource(ctx context.RequestContext, data Payload,
+	version string) (Resource, error) {
+	i := h.calls
+	h.calls++
+	if i == h.failOn {
+		return nil, fmt.Errorf("constraint violated")
+	}
+	return map[string]interface{}{"id": fmt.Sprintf("%d", i)}, nil
+}
+
+func (h *txResourceHandler) WithTransaction(fn func() error) error {
+	return fn()
+}
+
+func TestHandleBulkAtomicRollbackOverwritesFabricatedSuccesses(t *testing.T) {
+	handler := &txResourceHandler{failOn: 1}
+	api := NewRestApi()
+	h := requestHandler{RestApi: api}
+
+	body := `{"atomic":true,"items":[
+		{"op":"create","data":{}},
+		{"op":"create","data":{}},
+		{"op":"create","data":{}}
+	]}`
+	req := httptest.NewRequest("POST", "/api/v1/widgets/_bulk", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+
+	h.handleBulk("widgets", resourceLinks{}, handler)(rec, req)
+
+	if rec.Code != http.StatusConflict {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusConflict)
+	}
+
+	var resp struct {
+		Result []bulkResultItem `json:"result"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("failed to decode response body: %v", err)
+	}
+	if len(resp.Result) != 3 {
+		t.Fatalf("len(result) = %d, want 3", len(resp.Result))
+	}
+
+	for i, item := range resp.Result {
+		if i == 1 {
+			if item.Error == "" || strings.Contains(item.Error, "rolled back") {
+				t.Errorf("result[1].Error = %q, want the original create failure, not a rollback note", item.Error)
+			}
+			continue
+		}
+		if item.Status != http.StatusConflict {
+			t.Errorf("result[%d].Status = %d, want %d (rolled back)", i, item.Status, http.StatusConflict)
+		}
+		if !strings.Contains(item.Error, "rolled back") {
+			t.Errorf("result[%d].Error = %q, want it to note the item was rolled back, not report the fabricated success", i, item.Error)
+		}
+		if item.Result != nil {
+			t.Errorf("result[%d].Result = %v, want nil once rolled back", i, item.Result)
+		}
+	}
+}
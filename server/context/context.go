@@ -0,0 +1,177 @@
+// Package context provides RequestContext, the per-request state threaded through
+// a RestApi's handlers and into ResourceHandler calls.
+package context
+
+import (
+	stdcontext "context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// versionParam and resourceIDParam are the route parameter names used in the URI
+// templates returned by a ResourceHandler's *URI methods. They mirror
+// server.versionKey/server.resourceIDKey; duplicated here since server imports this
+// package and a shared constant would create an import cycle.
+const (
+	versionParam    = "version"
+	resourceIDParam = "resource_id"
+)
+
+// defaultLimit is used for ReadResourceList/StreamResourceList requests that don't
+// supply a "limit" query parameter.
+const defaultLimit = 100
+
+// RequestContext carries the data extracted from an incoming request, the
+// context.Context derived from it so downstream calls honor client disconnects and
+// server-side deadlines, and the outcome of handling the request (result, error,
+// status, next-page cursor) so it can be serialized once a handler returns.
+//
+// RequestContext is immutable; every Set* method returns a copy with the relevant
+// field updated rather than mutating the receiver.
+type RequestContext struct {
+	stdcontext.Context
+
+	request *http.Request
+
+	version        string
+	resourceID     string
+	limit          int
+	cursor         string
+	responseFormat string
+
+	result     interface{}
+	err        error
+	status     int
+	nextCursor string
+}
+
+// NewContext builds a RequestContext for r. If parent is nil, the embedded
+// context.Context is r.Context(), so it's canceled when the client disconnects;
+// otherwise parent is used as the base context, letting a caller that already has
+// one (e.g. from its own middleware) thread it through instead.
+func NewContext(parent stdcontext.Context, r *http.Request) RequestContext {
+	if parent == nil {
+		parent = r.Context()
+	}
+
+	query := r.URL.Query()
+	limit := defaultLimit
+	if l, err := strconv.Atoi(query.Get("limit")); err == nil && l > 0 {
+		limit = l
+	}
+
+	return RequestContext{
+		Context:        parent,
+		request:        r,
+		version:        r.PathValue(versionParam),
+		resourceID:     r.PathValue(resourceIDParam),
+		limit:          limit,
+		cursor:         query.Get("cursor"),
+		responseFormat: query.Get("format"),
+	}
+}
+
+// WithTimeout returns a copy of c whose embedded context.Context is bounded by
+// timeout, along with the stdcontext.CancelFunc the caller must invoke once the
+// request has been handled.
+func (c RequestContext) WithTimeout(timeout time.Duration) (RequestContext, stdcontext.CancelFunc) {
+	ctx, cancel := stdcontext.WithTimeout(c.Context, timeout)
+	c.Context = ctx
+	return c, cancel
+}
+
+// Version returns the "{version}" route parameter of the matched URI, e.g. "v1"
+// for a request to "/api/v1/widgets". The wildcard spans the whole path segment,
+// so the value includes whatever prefix the caller used, not just the numeric
+// part.
+func (c RequestContext) Version() string {
+	return c.version
+}
+
+// ResourceId returns the "{resource_id}" route parameter of the matched URI.
+func (c RequestContext) ResourceId() string {
+	return c.resourceID
+}
+
+// Limit returns the "limit" query parameter, defaulting to defaultLimit if it's
+// absent or not a positive integer.
+func (c RequestContext) Limit() int {
+	return c.limit
+}
+
+// Cursor returns the "cursor" query parameter the client supplied to request the
+// current page, or "" if this is the first page.
+func (c RequestContext) Cursor() string {
+	return c.cursor
+}
+
+// ResponseFormat returns the "format" query parameter used to negotiate the
+// response serialization (see RestApi.negotiateFormat).
+func (c RequestContext) ResponseFormat() string {
+	return c.responseFormat
+}
+
+// SetResult returns a copy of c carrying result as the value to serialize back to
+// the client.
+func (c RequestContext) SetResult(result interface{}) RequestContext {
+	c.result = result
+	return c
+}
+
+// Result returns the value set by SetResult.
+func (c RequestContext) Result() interface{} {
+	return c.result
+}
+
+// SetError returns a copy of c carrying err, to be serialized as an error response
+// instead of Result.
+func (c RequestContext) SetError(err error) RequestContext {
+	c.err = err
+	return c
+}
+
+// Error returns the error set by SetError, or nil.
+func (c RequestContext) Error() error {
+	return c.err
+}
+
+// SetStatus returns a copy of c carrying the HTTP status code to respond with.
+func (c RequestContext) SetStatus(status int) RequestContext {
+	c.status = status
+	return c
+}
+
+// Status returns the status set by SetStatus.
+func (c RequestContext) Status() int {
+	return c.status
+}
+
+// SetCursor returns a copy of c carrying the cursor for the "next" page link (see
+// NextURL), as returned by ResourceHandler.ReadResourceList.
+func (c RequestContext) SetCursor(cursor string) RequestContext {
+	c.nextCursor = cursor
+	return c
+}
+
+// NextURL returns the URL for the next page of a list response, built by
+// replacing the "cursor" query parameter of the current request with the cursor
+// set via SetCursor. It returns an error if no next cursor was set, i.e. the
+// current page is the last one.
+func (c RequestContext) NextURL() (string, error) {
+	if c.nextCursor == "" {
+		return "", fmt.Errorf("no next page")
+	}
+	return c.urlWithCursor(c.nextCursor), nil
+}
+
+// urlWithCursor returns the path and query of the request that produced c, with
+// its "cursor" query parameter set to cursor.
+func (c RequestContext) urlWithCursor(cursor string) string {
+	query := c.request.URL.Query()
+	query.Set("cursor", cursor)
+	u := url.URL{Path: c.request.URL.Path, RawQuery: query.Encode()}
+	return u.String()
+}
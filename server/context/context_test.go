@@ -0,0 +1,91 @@
+package context
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func newTestRequest(target string) *http.Request {
+	r := httptest.NewRequest("GET", target, nil)
+	r.SetPathValue(versionParam, "1")
+	r.SetPathValue(resourceIDParam, "42")
+	return r
+}
+
+func TestNewContextExtractsRequestData(t *testing.T) {
+	r := newTestRequest("/api/v1/widgets?limit=10&cursor=abc&format=xml")
+	ctx := NewContext(nil, r)
+
+	if ctx.Version() != "1" {
+		t.Errorf("Version() = %q, want %q", ctx.Version(), "1")
+	}
+	if ctx.ResourceId() != "42" {
+		t.Errorf("ResourceId() = %q, want %q", ctx.ResourceId(), "42")
+	}
+	if ctx.Limit() != 10 {
+		t.Errorf("Limit() = %d, want 10", ctx.Limit())
+	}
+	if ctx.Cursor() != "abc" {
+		t.Errorf("Cursor() = %q, want %q", ctx.Cursor(), "abc")
+	}
+	if ctx.ResponseFormat() != "xml" {
+		t.Errorf("ResponseFormat() = %q, want %q", ctx.ResponseFormat(), "xml")
+	}
+}
+
+func TestNewContextDefaultLimit(t *testing.T) {
+	ctx := NewContext(nil, newTestRequest("/api/v1/widgets"))
+	if ctx.Limit() != defaultLimit {
+		t.Errorf("Limit() = %d, want default %d", ctx.Limit(), defaultLimit)
+	}
+}
+
+func TestWithTimeoutExpires(t *testing.T) {
+	ctx, cancel := NewContext(nil, newTestRequest("/api/v1/widgets")).WithTimeout(time.Millisecond)
+	defer cancel()
+
+	<-ctx.Done()
+	if ctx.Err() != context.DeadlineExceeded {
+		t.Errorf("Err() = %v, want %v", ctx.Err(), context.DeadlineExceeded)
+	}
+}
+
+func TestNextURLRequiresCursor(t *testing.T) {
+	ctx := NewContext(nil, newTestRequest("/api/v1/widgets"))
+	if _, err := ctx.NextURL(); err == nil {
+		t.Error("NextURL() err = nil, want error when no cursor was set")
+	}
+
+	ctx = ctx.SetCursor("next-page")
+	next, err := ctx.NextURL()
+	if err != nil {
+		t.Fatalf("NextURL() err = %v, want nil", err)
+	}
+	if next != "/api/v1/widgets?cursor=next-page" {
+		t.Errorf("NextURL() = %q, want %q", next, "/api/v1/widgets?cursor=next-page")
+	}
+}
+
+func TestSetResultAndSetErrorAreImmutable(t *testing.T) {
+	base := NewContext(nil, newTestRequest("/api/v1/widgets"))
+
+	withResult := base.SetResult("widget")
+	if base.Result() != nil {
+		t.Errorf("base.Result() = %v, want nil after SetResult on a copy", base.Result())
+	}
+	if withResult.Result() != "widget" {
+		t.Errorf("withResult.Result() = %v, want %q", withResult.Result(), "widget")
+	}
+
+	withError := base.SetError(errors.New("boom"))
+	if base.Error() != nil {
+		t.Errorf("base.Error() = %v, want nil after SetError on a copy", base.Error())
+	}
+	if withError.Error() == nil || withError.Error().Error() != "boom" {
+		t.Errorf("withError.Error() = %v, want %q", withError.Error(), "boom")
+	}
+}
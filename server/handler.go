@@ -1,13 +1,16 @@
 package server
 
 import (
-	"encoding/json"
-
+	stdcontext "context"
 	"net/http"
 
 	"go-rest/server/context"
 )
 
+// statusClientClosedRequest is the nginx-originated status used to report that the
+// client disconnected before the response was ready. It has no net/http constant.
+const statusClientClosedRequest = 499
+
 // Resource represents a domain model.
 type Resource interface{}
 
@@ -19,11 +22,29 @@ type Payload map[string]interface{}
 type ResourceHandler interface {
 	ResourceName() string
 	CreateResource(context.RequestContext, Payload, string) (Resource, error)
+
+	// ReadResourceList returns up to limit resources starting after the request's
+	// cursor (see context.RequestContext.Cursor), along with the cursor for the
+	// next page or "" if this is the last one. The cursor is forward-only: there's
+	// no token that names the page before the current one, so the "prev" Link rel
+	// handleReadList emits (see paginationLinks) always points at the first page
+	// rather than truly reversing one page at a time.
 	ReadResourceList(context.RequestContext, int, string) ([]Resource, string, error)
 	ReadResource(context.RequestContext, string, string) (Resource, error)
 	UpdateResource(context.RequestContext, string, Payload, string) (Resource, error)
 	DeleteResource(context.RequestContext, string, string) (Resource, error)
 	IsAuthorized(http.Request) bool
+
+	// ReadURI, ReadListURI, UpdateURI, and DeleteURI return the route templates used
+	// to derive the hypermedia Link header and "_links" envelope for this resource.
+	// An empty string falls back to the default "/api/{version}/resourceName[/{resource_id}]"
+	// pattern. "{version}" must occupy its whole path segment (e.g. match "v1", not
+	// sit next to a literal "v"), since http.ServeMux requires wildcards to span an
+	// entire segment.
+	ReadURI() string
+	ReadListURI() string
+	UpdateURI() string
+	DeleteURI() string
 }
 
 type BaseResourceHandler struct{}
@@ -32,6 +53,26 @@ func (b BaseResourceHandler) ResourceName() string {
 	panic("ResourceName not implemented")
 }
 
+// ReadURI is a stub. Implement if the resource is served at a non-default URI.
+func (b BaseResourceHandler) ReadURI() string {
+	return ""
+}
+
+// ReadListURI is a stub. Implement if the resource is served at a non-default URI.
+func (b BaseResourceHandler) ReadListURI() string {
+	return ""
+}
+
+// UpdateURI is a stub. Implement if the resource is served at a non-default URI.
+func (b BaseResourceHandler) UpdateURI() string {
+	return ""
+}
+
+// DeleteURI is a stub. Implement if the resource is served at a non-default URI.
+func (b BaseResourceHandler) DeleteURI() string {
+	return ""
+}
+
 func (b BaseResourceHandler) CreateResource(ctx context.RequestContext, data Payload,
 	version string) (Resource, error) {
 	panic("CreateResource not implemented")
@@ -62,21 +103,42 @@ func (b BaseResourceHandler) IsAuthorized(r http.Request) bool {
 }
 
 // requestHandler constructs http.HandlerFuncs responsible for handling HTTP requests.
+// It holds a *RestApi rather than a copy so that configuration methods
+// (EnableMetrics, SetRequestTimeout, EnableHypermediaEnvelope, RegisterSerializer)
+// called after RegisterResource still take effect: RegisterResource builds one
+// requestHandler per resource up front, and its closures keep calling into it for
+// the lifetime of the RestApi.
 type requestHandler struct {
-	RestApi
+	*RestApi
+}
+
+// newContext builds the context.RequestContext for r. The RequestContext embeds the
+// context.Context derived from r.Context(), so it's canceled when the client
+// disconnects; if the RestApi has a request timeout configured, it's also bounded
+// by that timeout. Callers must invoke the returned CancelFunc once the request has
+// been handled.
+func (h requestHandler) newContext(r *http.Request) (context.RequestContext, stdcontext.CancelFunc) {
+	ctx := context.NewContext(nil, r)
+	if h.requestTimeout <= 0 {
+		return ctx, func() {}
+	}
+	return ctx.WithTimeout(h.requestTimeout)
 }
 
 // handleCreate returns a HandlerFunc which will deserialize the request payload, pass
 // it to the provided create function, and then serialize and dispatch the response.
-// The serialization mechanism used is specified by the "format" query parameter.
-func (h requestHandler) handleCreate(createFunc func(context.RequestContext, Payload,
-	string) (Resource, error)) http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
-		ctx := context.NewContext(nil, r)
+// The request body is decoded according to the "Content-Type" header, and the
+// response is serialized according to the negotiated format (see sendResponse). If
+// metrics are enabled, the request is instrumented under resourceName.
+func (h requestHandler) handleCreate(resourceName string, links resourceLinks,
+	createFunc func(context.RequestContext, Payload, string) (Resource, error)) http.HandlerFunc {
+	return h.instrument(resourceName, "POST", func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := h.newContext(r)
+		defer cancel()
 
-		decoder := json.NewDecoder(r.Body)
 		var data map[string]interface{}
-		if err := decoder.Decode(&data); err != nil {
+		decoder := h.requestSerializer(r.Header.Get("Content-Type"))
+		if err := decoder.decode(r.Body, &data); err != nil {
 			ctx = ctx.SetError(err)
 			ctx = ctx.SetStatus(http.StatusInternalServerError)
 		} else {
@@ -88,17 +150,19 @@ func (h requestHandler) handleCreate(createFunc func(context.RequestContext, Pay
 			}
 		}
 
-		h.sendResponse(w, ctx)
-	}
+		h.sendResponse(w, r, ctx, links)
+	})
 }
 
 // handleReadList returns a HandlerFunc which will pass the request context to the
 // provided read function and then serialize and dispatch the response. The
-// serialization mechanism used is specified by the "format" query parameter.
-func (h requestHandler) handleReadList(readFunc func(context.RequestContext, int,
-	string) ([]Resource, string, error)) http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
-		ctx := context.NewContext(nil, r)
+// serialization mechanism used is negotiated from the request (see sendResponse). If
+// metrics are enabled, the request is instrumented under resourceName.
+func (h requestHandler) handleReadList(resourceName string, links resourceLinks,
+	readFunc func(context.RequestContext, int, string) ([]Resource, string, error)) http.HandlerFunc {
+	return h.instrument(resourceName, "GET", func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := h.newContext(r)
+		defer cancel()
 
 		resources, cursor, err := readFunc(ctx, ctx.Limit(), ctx.Version())
 		ctx = ctx.SetResult(resources)
@@ -106,39 +170,44 @@ func (h requestHandler) handleReadList(readFunc func(context.RequestContext, int
 		ctx = ctx.SetError(err)
 		ctx = ctx.SetStatus(http.StatusOK)
 
-		h.sendResponse(w, ctx)
-	}
+		paginationLinks(w, ctx, resolveURI(links.collection, ctx.Version(), ""))
+		h.sendResponse(w, r, ctx, resourceLinks{collection: links.collection})
+	})
 }
 
 // handleRead returns a HandlerFunc which will pass the resource id to the provided
 // read function and then serialize and dispatch the response. The serialization
-// mechanism used is specified by the "format" query parameter.
-func (h requestHandler) handleRead(readFunc func(context.RequestContext, string,
-	string) (Resource, error)) http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
-		ctx := context.NewContext(nil, r)
+// mechanism used is negotiated from the request (see sendResponse). If metrics are
+// enabled, the request is instrumented under resourceName.
+func (h requestHandler) handleRead(resourceName string, links resourceLinks,
+	readFunc func(context.RequestContext, string, string) (Resource, error)) http.HandlerFunc {
+	return h.instrument(resourceName, "GET", func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := h.newContext(r)
+		defer cancel()
 
 		resource, err := readFunc(ctx, ctx.ResourceId(), ctx.Version())
 		ctx = ctx.SetResult(resource)
 		ctx = ctx.SetError(err)
 		ctx = ctx.SetStatus(http.StatusOK)
 
-		h.sendResponse(w, ctx)
-	}
+		h.sendResponse(w, r, ctx, links)
+	})
 }
 
 // handleUpdate returns a HandlerFunc which will deserialize the request payload,
 // pass it to the provided update function, and then serialize and dispatch the
-// response. The serialization mechanism used is specified by the "format" query
-// parameter.
-func (h requestHandler) handleUpdate(updateFunc func(context.RequestContext,
-	string, Payload, string) (Resource, error)) http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
-		ctx := context.NewContext(nil, r)
-
-		decoder := json.NewDecoder(r.Body)
+// response. The request body is decoded according to the "Content-Type" header, and
+// the response is serialized according to the negotiated format (see sendResponse).
+// If metrics are enabled, the request is instrumented under resourceName.
+func (h requestHandler) handleUpdate(resourceName string, links resourceLinks,
+	updateFunc func(context.RequestContext, string, Payload, string) (Resource, error)) http.HandlerFunc {
+	return h.instrument(resourceName, "PUT", func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := h.newContext(r)
+		defer cancel()
+
 		var data map[string]interface{}
-		if err := decoder.Decode(&data); err != nil {
+		decoder := h.requestSerializer(r.Header.Get("Content-Type"))
+		if err := decoder.decode(r.Body, &data); err != nil {
 			ctx = ctx.SetError(err)
 			ctx = ctx.SetStatus(http.StatusInternalServerError)
 		} else {
@@ -148,35 +217,52 @@ func (h requestHandler) handleUpdate(updateFunc func(context.RequestContext,
 			ctx = ctx.SetStatus(http.StatusOK)
 		}
 
-		h.sendResponse(w, ctx)
-	}
+		h.sendResponse(w, r, ctx, links)
+	})
 }
 
 // handleDelete returns a HandlerFunc which will pass the resource id to the provided
 // delete function and then serialize and dispatch the response. The serialization
-// mechanism used is specified by the "format" query parameter.
-func (h requestHandler) handleDelete(deleteFunc func(context.RequestContext, string,
-	string) (Resource, error)) http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
-		ctx := context.NewContext(nil, r)
+// mechanism used is negotiated from the request (see sendResponse). If metrics are
+// enabled, the request is instrumented under resourceName.
+func (h requestHandler) handleDelete(resourceName string, links resourceLinks,
+	deleteFunc func(context.RequestContext, string, string) (Resource, error)) http.HandlerFunc {
+	return h.instrument(resourceName, "DELETE", func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := h.newContext(r)
+		defer cancel()
 
 		resource, err := deleteFunc(ctx, ctx.ResourceId(), ctx.Version())
 		ctx = ctx.SetResult(resource)
 		ctx = ctx.SetError(err)
 		ctx = ctx.SetStatus(http.StatusOK)
 
-		h.sendResponse(w, ctx)
-	}
+		h.sendResponse(w, r, ctx, links)
+	})
 }
 
 // sendResponse writes a success or error response to the provided http.ResponseWriter
-// based on the contents of the context.RequestContext.
-func (h requestHandler) sendResponse(w http.ResponseWriter, ctx context.RequestContext) {
+// based on the contents of the context.RequestContext. The response format is
+// negotiated from the "format" query parameter and, failing that, the request's
+// Accept header. On success, links is resolved against the request's version and
+// resource id to set the RFC 5988 Link header and, if the RestApi has hypermedia
+// envelopes enabled, the "_links" field of the response body.
+func (h requestHandler) sendResponse(w http.ResponseWriter, r *http.Request,
+	ctx context.RequestContext, links resourceLinks) {
+	switch ctx.Err() {
+	case stdcontext.Canceled:
+		jsonSerializer{}.sendErrorResponse(w, ctx.Err(), statusClientClosedRequest)
+		return
+	case stdcontext.DeadlineExceeded:
+		jsonSerializer{}.sendErrorResponse(w, ctx.Err(), http.StatusGatewayTimeout)
+		return
+	}
+
 	status := ctx.Status()
 	requestError := ctx.Error()
 	result := ctx.Result()
 
-	serializer, err := h.responseSerializer(ctx.ResponseFormat())
+	format := h.negotiateFormat(r, ctx.ResponseFormat())
+	serializer, err := h.responseSerializer(format)
 	if err != nil {
 		// Fall back to json serialization.
 		serializer = jsonSerializer{}
@@ -192,6 +278,14 @@ func (h requestHandler) sendResponse(w http.ResponseWriter, ctx context.RequestC
 		return
 	}
 
+	version, resourceID := ctx.Version(), ctx.ResourceId()
+	writeLinkHeader(w, links, version, resourceID)
+
+	var linksEnv map[string]hateoasLink
+	if h.hypermediaEnvelope {
+		linksEnv = linksEnvelope(links, version, resourceID)
+	}
+
 	nextURL, _ := ctx.NextURL()
-	serializer.sendSuccessResponse(w, newSuccessResponse(result, nextURL), status)
+	serializer.sendSuccessResponse(w, newSuccessResponse(result, nextURL, linksEnv), status)
 }
@@ -0,0 +1,193 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"go-rest/server/context"
+)
+
+// versionKey and resourceIDKey are the route parameter names used in URI templates
+// returned by ResourceHandler.*URI methods, e.g. "/api/{version}/widgets/{resource_id}".
+const (
+	versionKey    = "version"
+	resourceIDKey = "resource_id"
+)
+
+// uriParam matches a mux-style route parameter such as "{version:[^/]+}" or
+// "{resource_id}", capturing just the parameter name.
+var uriParam = regexp.MustCompile(`\{(\w+)(:[^}]+)?\}`)
+
+// resolveURI fills in a route template (as returned by a ResourceHandler's URI
+// methods) with the given version and resource id, producing a concrete path.
+func resolveURI(template, version, resourceID string) string {
+	return uriParam.ReplaceAllStringFunc(template, func(match string) string {
+		name := uriParam.FindStringSubmatch(match)[1]
+		switch name {
+		case versionKey:
+			return version
+		case resourceIDKey:
+			return resourceID
+		default:
+			return match
+		}
+	})
+}
+
+// muxPattern strips the "{name:regex}" constraint from a route template, leaving
+// the plain "{name}" wildcard syntax http.ServeMux's pattern matching understands,
+// e.g. "/api/{version:[^/]+}/widgets" becomes "/api/{version}/widgets". A
+// ResourceHandler that overrides a *URI method with a regex-constrained template
+// must still give the wildcard its own path segment (http.ServeMux requires a
+// "{name}" to span the whole segment), since muxPattern only strips the
+// constraint, not the surrounding literal text.
+func muxPattern(template string) string {
+	return uriParam.ReplaceAllStringFunc(template, func(match string) string {
+		name := uriParam.FindStringSubmatch(match)[1]
+		return "{" + name + "}"
+	})
+}
+
+// defaultURI returns the conventional route template for a resource, optionally
+// including the {resource_id} segment, used when a ResourceHandler's URI method
+// returns "". The "{version}" wildcard occupies its whole path segment, as
+// http.ServeMux requires, so a version like "v1" is part of the captured value
+// (see context.RequestContext.Version) rather than a literal "v" outside the
+// wildcard.
+func defaultURI(resourceName string, withID bool) string {
+	uri := fmt.Sprintf("/api/{%s}/%s", versionKey, resourceName)
+	if withID {
+		uri += fmt.Sprintf("/{%s}", resourceIDKey)
+	}
+	return uri
+}
+
+// resourceHandlerLinks builds the resourceLinks for h, falling back to the
+// conventional URI for any template h leaves unspecified.
+func resourceHandlerLinks(h ResourceHandler) resourceLinks {
+	links := resourceLinks{
+		self:       h.ReadURI(),
+		edit:       h.UpdateURI(),
+		delete:     h.DeleteURI(),
+		collection: h.ReadListURI(),
+	}
+	if links.self == "" {
+		links.self = defaultURI(h.ResourceName(), true)
+	}
+	if links.edit == "" {
+		links.edit = defaultURI(h.ResourceName(), true)
+	}
+	if links.delete == "" {
+		links.delete = defaultURI(h.ResourceName(), true)
+	}
+	if links.collection == "" {
+		links.collection = defaultURI(h.ResourceName(), false)
+	}
+	return links
+}
+
+// resourceLinks carries the route templates needed to build the Link header and,
+// when the hypermedia envelope is enabled, the "_links" object for a response.
+type resourceLinks struct {
+	self       string
+	edit       string
+	delete     string
+	collection string
+}
+
+// hateoasLink is a single entry in a successResponse's "_links" envelope.
+type hateoasLink struct {
+	Href string `json:"href"`
+}
+
+// writeLinkHeader sets an RFC 5988 Link header on w built from links, resolving
+// route templates against version and resourceID. Empty templates are skipped.
+func writeLinkHeader(w http.ResponseWriter, links resourceLinks, version, resourceID string) {
+	var rels []string
+	add := func(rel, template string) {
+		if template == "" {
+			return
+		}
+		rels = append(rels, fmt.Sprintf(`<%s>; rel="%s"`, resolveURI(template, version, resourceID), rel))
+	}
+
+	add("self", links.self)
+	add("edit", links.edit)
+	add("delete", links.delete)
+	add("collection", links.collection)
+
+	if len(rels) == 0 {
+		return
+	}
+
+	if existing := w.Header().Get("Link"); existing != "" {
+		rels = append([]string{existing}, rels...)
+	}
+	w.Header().Set("Link", strings.Join(rels, ", "))
+}
+
+// linksEnvelope builds the "_links" map embedded in a successResponse when the
+// hypermedia envelope is enabled on the RestApi.
+func linksEnvelope(links resourceLinks, version, resourceID string) map[string]hateoasLink {
+	envelope := map[string]hateoasLink{}
+	add := func(rel, template string) {
+		if template == "" {
+			return
+		}
+		envelope[rel] = hateoasLink{Href: resolveURI(template, version, resourceID)}
+	}
+
+	add("self", links.self)
+	add("edit", links.edit)
+	add("delete", links.delete)
+	add("collection", links.collection)
+
+	if len(envelope) == 0 {
+		return nil
+	}
+	return envelope
+}
+
+// paginationLinks builds the "next"/"prev"/"first" Link header entries for a list
+// response using the existing cursor mechanism. Unlike "next" and "first", "prev"
+// is not a true reverse link: the cursor only ever names the next page, so there's
+// no token that names the page before this one (see ResourceHandler.ReadResourceList).
+// "prev" is only accurate one hop back, from page 2 to page 1; from page 3 onward
+// it still points at the first page while asserting rel="prev", which a caller
+// expecting RFC 5988 semantics (each rel taking them exactly one page in that
+// direction) would find misleading.
+func paginationLinks(w http.ResponseWriter, ctx context.RequestContext, firstURL string) {
+	var rels []string
+
+	if nextURL, err := ctx.NextURL(); err == nil && nextURL != "" {
+		rels = append(rels, fmt.Sprintf(`<%s>; rel="next"`, nextURL))
+	}
+	// The cursor mechanism only ever hands the client a forward "next" token, so
+	// there's no cursor that names the page before this one. ctx.Cursor() being
+	// non-empty at least means this isn't the first page, so point "prev" back at
+	// the collection's first page rather than omitting the rel entirely.
+	if ctx.Cursor() != "" && firstURL != "" {
+		rels = append(rels, fmt.Sprintf(`<%s>; rel="prev"`, firstURL))
+	}
+	if firstURL != "" {
+		rels = append(rels, fmt.Sprintf(`<%s>; rel="first"`, firstURL))
+	}
+
+	if len(rels) > 0 {
+		existing := w.Header().Get("Link")
+		if existing != "" {
+			rels = append([]string{existing}, rels...)
+		}
+		w.Header().Set("Link", strings.Join(rels, ", "))
+	}
+}
+
+// EnableHypermediaEnvelope turns on the "_links" object embedded in successResponse
+// bodies alongside the Link header, which is always set. It's off by default so
+// that existing clients which only read the "result" field aren't affected by the
+// extra field.
+func (r *RestApi) EnableHypermediaEnvelope() {
+	r.hypermediaEnvelope = true
+}
@@ -0,0 +1,131 @@
+package server
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"go-rest/server/context"
+)
+
+func TestPaginationLinksFirstPage(t *testing.T) {
+	ctx := context.NewContext(nil, httptest.NewRequest("GET", "/api/v1/widgets", nil))
+	ctx = ctx.SetCursor("next-token")
+	rec := httptest.NewRecorder()
+
+	paginationLinks(rec, ctx, "/api/v1/widgets")
+
+	link := rec.Header().Get("Link")
+	if !strings.Contains(link, `rel="next"`) {
+		t.Errorf("Link = %q, want a next rel", link)
+	}
+	if !strings.Contains(link, `rel="first"`) {
+		t.Errorf("Link = %q, want a first rel", link)
+	}
+	if strings.Contains(link, `rel="prev"`) {
+		t.Errorf("Link = %q, want no prev rel on the first page", link)
+	}
+}
+
+func TestPaginationLinksLaterPage(t *testing.T) {
+	ctx := context.NewContext(nil, httptest.NewRequest("GET", "/api/v1/widgets?cursor=abc", nil))
+	rec := httptest.NewRecorder()
+
+	paginationLinks(rec, ctx, "/api/v1/widgets")
+
+	link := rec.Header().Get("Link")
+	if !strings.Contains(link, `rel="prev"`) {
+		t.Errorf("Link = %q, want a prev rel once an incoming cursor is present", link)
+	}
+}
+
+func TestResolveURI(t *testing.T) {
+	got := resolveURI(defaultURI("widgets", true), "v1", "42")
+	want := "/api/v1/widgets/42"
+	if got != want {
+		t.Errorf("resolveURI() = %q, want %q", got, want)
+	}
+}
+
+// linkTestHandler is a minimal ResourceHandler used to exercise
+// resourceHandlerLinks' fallback to the default URI templates.
+type linkTestHandler struct {
+	BaseResourceHandler
+}
+
+func (linkTestHandler) ResourceName() string { return "widgets" }
+
+func TestResourceHandlerLinksFallsBackToDefaultURI(t *testing.T) {
+	links := resourceHandlerLinks(linkTestHandler{})
+
+	for rel, template := range map[string]string{
+		"self":       links.self,
+		"edit":       links.edit,
+		"delete":     links.delete,
+		"collection": links.collection,
+	} {
+		if template == "" {
+			t.Errorf("links.%s = \"\", want the default URI template", rel)
+		}
+	}
+	if links.collection != defaultURI("widgets", false) {
+		t.Errorf("links.collection = %q, want %q", links.collection, defaultURI("widgets", false))
+	}
+}
+
+func TestWriteLinkHeader(t *testing.T) {
+	links := resourceLinks{
+		self:       defaultURI("widgets", true),
+		edit:       defaultURI("widgets", true),
+		delete:     defaultURI("widgets", true),
+		collection: defaultURI("widgets", false),
+	}
+	rec := httptest.NewRecorder()
+
+	writeLinkHeader(rec, links, "v1", "42")
+
+	link := rec.Header().Get("Link")
+	for _, want := range []string{
+		`</api/v1/widgets/42>; rel="self"`,
+		`</api/v1/widgets/42>; rel="edit"`,
+		`</api/v1/widgets/42>; rel="delete"`,
+		`</api/v1/widgets>; rel="collection"`,
+	} {
+		if !strings.Contains(link, want) {
+			t.Errorf("Link = %q, want it to contain %q", link, want)
+		}
+	}
+}
+
+func TestWriteLinkHeaderSkipsEmptyTemplates(t *testing.T) {
+	rec := httptest.NewRecorder()
+	writeLinkHeader(rec, resourceLinks{}, "v1", "42")
+	if link := rec.Header().Get("Link"); link != "" {
+		t.Errorf("Link = %q, want empty when no templates are set", link)
+	}
+}
+
+func TestLinksEnvelope(t *testing.T) {
+	links := resourceLinks{
+		self:       defaultURI("widgets", true),
+		collection: defaultURI("widgets", false),
+	}
+
+	envelope := linksEnvelope(links, "v1", "42")
+
+	if envelope["self"].Href != "/api/v1/widgets/42" {
+		t.Errorf("envelope[self].Href = %q, want %q", envelope["self"].Href, "/api/v1/widgets/42")
+	}
+	if envelope["collection"].Href != "/api/v1/widgets" {
+		t.Errorf("envelope[collection].Href = %q, want %q", envelope["collection"].Href, "/api/v1/widgets")
+	}
+	if _, ok := envelope["edit"]; ok {
+		t.Error("envelope[edit] present, want it omitted since UpdateURI() was empty")
+	}
+}
+
+func TestLinksEnvelopeEmptyWhenNoTemplates(t *testing.T) {
+	if envelope := linksEnvelope(resourceLinks{}, "v1", "42"); envelope != nil {
+		t.Errorf("linksEnvelope() = %v, want nil", envelope)
+	}
+}
@@ -0,0 +1,131 @@
+package server
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// defaultMetricsPath is where metrics are exposed when EnableMetrics is called
+// without a subsequent call to SetMetricsPath.
+const defaultMetricsPath = "/metrics"
+
+// apiMetrics holds the Prometheus collectors registered for a RestApi. It's nil on
+// a RestApi that hasn't called EnableMetrics, in which case instrumentation is a
+// no-op.
+type apiMetrics struct {
+	registry *prometheus.Registry
+	requests *prometheus.CounterVec
+	latency  *prometheus.HistogramVec
+	size     *prometheus.HistogramVec
+}
+
+// EnableMetrics registers Prometheus counter and histogram collectors under
+// namespace and exposes them at the metrics path (defaulting to "/metrics", see
+// SetMetricsPath). Requests to every registered ResourceHandler's endpoints are then
+// instrumented automatically. Consumers who don't want the prometheus dependency can
+// simply never call this.
+//
+// The collectors are registered against a prometheus.Registry private to this
+// RestApi rather than the global default registry, so a second RestApi (or a
+// second call to EnableMetrics) in the same process doesn't panic with an
+// AlreadyRegisteredError.
+func (r *RestApi) EnableMetrics(namespace string) {
+	registry := prometheus.NewRegistry()
+	r.metrics = &apiMetrics{
+		registry: registry,
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "http_requests_total",
+			Help:      "Total number of HTTP requests handled, labeled by resource, method, and status class.",
+		}, []string{"resource", "method", "status"}),
+		latency: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "http_request_duration_seconds",
+			Help:      "HTTP request latency in seconds, labeled by resource and method.",
+		}, []string{"resource", "method"}),
+		size: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "http_response_size_bytes",
+			Help:      "HTTP response size in bytes, labeled by resource and method.",
+		}, []string{"resource", "method"}),
+	}
+
+	registry.MustRegister(r.metrics.requests, r.metrics.latency, r.metrics.size)
+
+	if r.metricsPath == "" {
+		r.metricsPath = defaultMetricsPath
+	}
+	r.mux.Handle(r.metricsPath, promhttp.HandlerFor(registry, promhttp.HandlerOpts{}))
+}
+
+// SetMetricsPath overrides the path metrics are exposed at. It must be called before
+// EnableMetrics. If not called, metrics are exposed at "/metrics".
+func (r *RestApi) SetMetricsPath(path string) {
+	r.metricsPath = path
+}
+
+// statusRecorder wraps an http.ResponseWriter to capture the status code and byte
+// count written in order to report them after the wrapped handler returns.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+	size   int
+}
+
+func (s *statusRecorder) WriteHeader(status int) {
+	s.status = status
+	s.ResponseWriter.WriteHeader(status)
+}
+
+func (s *statusRecorder) Write(b []byte) (int, error) {
+	if s.status == 0 {
+		s.status = http.StatusOK
+	}
+	n, err := s.ResponseWriter.Write(b)
+	s.size += n
+	return n, err
+}
+
+// Flush delegates to the wrapped ResponseWriter's http.Flusher if it implements
+// one, and is a no-op otherwise. http.ResponseWriter is embedded as an interface,
+// so Go doesn't promote Flush from it automatically; without this, wrapping a
+// ResponseWriter in a statusRecorder (as instrument does) would silently defeat
+// handleStreamList's `w.(http.Flusher)` check whenever metrics are enabled.
+func (s *statusRecorder) Flush() {
+	if flusher, ok := s.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// instrument wraps next so that, when metrics are enabled, it records the outcome of
+// the request (after sendResponse writes the status) against resourceName and
+// method. Whether metrics are enabled is checked on every request rather than once
+// when the route is registered, since EnableMetrics may be called after
+// RegisterResource.
+func (h requestHandler) instrument(resourceName, method string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if h.metrics == nil {
+			next(w, r)
+			return
+		}
+
+		recorder := &statusRecorder{ResponseWriter: w}
+		start := time.Now()
+
+		next(recorder, r)
+
+		status := recorder.status
+		if status == 0 {
+			status = http.StatusOK
+		}
+		statusClass := strconv.Itoa(status/100) + "xx"
+
+		h.metrics.requests.WithLabelValues(resourceName, method, statusClass).Inc()
+		h.metrics.latency.WithLabelValues(resourceName, method).Observe(time.Since(start).Seconds())
+		h.metrics.size.WithLabelValues(resourceName, method).Observe(float64(recorder.size))
+	}
+}
@@ -0,0 +1,111 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+
+	"go-rest/server/context"
+)
+
+func TestEnableMetricsTwiceDoesNotPanic(t *testing.T) {
+	// Each RestApi registers its collectors against its own prometheus.Registry,
+	// so a second RestApi in the same process (or EnableMetrics called twice)
+	// must not panic with an AlreadyRegisteredError against the global registry.
+	first := NewRestApi()
+	first.EnableMetrics("first")
+
+	second := NewRestApi()
+	second.EnableMetrics("second")
+}
+
+func TestInstrumentRecordsRequestOutcome(t *testing.T) {
+	api := NewRestApi()
+	api.EnableMetrics("test")
+	h := requestHandler{RestApi: api}
+
+	handler := h.instrument("widgets", "GET", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	handler(httptest.NewRecorder(), httptest.NewRequest("GET", "/api/v1/widgets", nil))
+
+	if got := testutil.ToFloat64(api.metrics.requests.WithLabelValues("widgets", "GET", "2xx")); got != 1 {
+		t.Errorf("requests counter = %v, want 1", got)
+	}
+	if got := testutil.CollectAndCount(api.metrics.latency); got != 1 {
+		t.Errorf("latency histogram series count = %d, want 1", got)
+	}
+}
+
+func TestInstrumentRecordsErrorStatusClass(t *testing.T) {
+	api := NewRestApi()
+	api.EnableMetrics("test")
+	h := requestHandler{RestApi: api}
+
+	handler := h.instrument("widgets", "POST", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	})
+	handler(httptest.NewRecorder(), httptest.NewRequest("POST", "/api/v1/widgets", nil))
+
+	if got := testutil.ToFloat64(api.metrics.requests.WithLabelValues("widgets", "POST", "5xx")); got != 1 {
+		t.Errorf("requests counter = %v, want 1", got)
+	}
+}
+
+func TestEnableMetricsAfterRegisterResourceStillInstruments(t *testing.T) {
+	// RegisterResource builds its requestHandler once, up front, so it must hold
+	// a pointer back to the RestApi rather than a snapshot of it at that moment —
+	// otherwise EnableMetrics called afterward (a natural order: register
+	// handlers as they're discovered, turn on cross-cutting features once setup
+	// is done) would leave every already-registered resource uninstrumented.
+	api := NewRestApi()
+	api.RegisterResource(metricsTestHandler{})
+	api.EnableMetrics("test")
+
+	rec := httptest.NewRecorder()
+	api.mux.ServeHTTP(rec, httptest.NewRequest("GET", "/api/v1/widgets", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("GET /api/v1/widgets status = %d, want %d", rec.Code, http.StatusOK)
+	}
+
+	if got := testutil.ToFloat64(api.metrics.requests.WithLabelValues("widgets", "GET", "2xx")); got != 1 {
+		t.Errorf("requests counter = %v, want 1", got)
+	}
+}
+
+func TestMetricsEndpointServesRegisteredResourceMetrics(t *testing.T) {
+	api := NewRestApi()
+	api.EnableMetrics("test")
+	api.RegisterResource(metricsTestHandler{})
+
+	rec := httptest.NewRecorder()
+	api.mux.ServeHTTP(rec, httptest.NewRequest("GET", "/api/v1/widgets", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("GET /api/v1/widgets status = %d, want %d", rec.Code, http.StatusOK)
+	}
+
+	rec = httptest.NewRecorder()
+	api.mux.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("GET /metrics status = %d, want %d", rec.Code, http.StatusOK)
+	}
+	if !strings.Contains(rec.Body.String(), `test_http_requests_total{method="GET",resource="widgets",status="2xx"} 1`) {
+		t.Errorf("/metrics body = %q, want it to report the widgets GET request", rec.Body.String())
+	}
+}
+
+// metricsTestHandler is a minimal ResourceHandler used to verify a request routed
+// through RegisterResource is instrumented and reflected at the metrics endpoint.
+type metricsTestHandler struct {
+	BaseResourceHandler
+}
+
+func (metricsTestHandler) ResourceName() string { return "widgets" }
+
+func (metricsTestHandler) ReadResourceList(ctx context.RequestContext, limit int,
+	version string) ([]Resource, string, error) {
+	return nil, "", nil
+}
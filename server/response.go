@@ -0,0 +1,25 @@
+package server
+
+// successResponse is the envelope written for any non-error response.
+type successResponse struct {
+	Result  Resource               `json:"result"`
+	NextURL string                 `json:"nextUrl,omitempty"`
+	Links   map[string]hateoasLink `json:"_links,omitempty"`
+}
+
+// newSuccessResponse constructs a successResponse wrapping result, optionally
+// including a cursor URL for paginated list responses and a "_links" hypermedia
+// envelope when enabled via RestApi.EnableHypermediaEnvelope.
+func newSuccessResponse(result Resource, nextURL string, links map[string]hateoasLink) successResponse {
+	return successResponse{Result: result, NextURL: nextURL, Links: links}
+}
+
+// errorResponse is the envelope written when a request fails.
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+// newErrorResponse constructs an errorResponse from err.
+func newErrorResponse(err error) errorResponse {
+	return errorResponse{Error: err.Error()}
+}
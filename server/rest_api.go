@@ -0,0 +1,167 @@
+package server
+
+import (
+	"fmt"
+	"mime"
+	"net/http"
+	"time"
+)
+
+// defaultMediaType is the serializer used when content negotiation doesn't find an
+// explicit match.
+const defaultMediaType = "application/json"
+
+// RestApi wires together registered ResourceHandlers and serves them over HTTP.
+type RestApi struct {
+	mux                *http.ServeMux
+	serializers        map[string]ResponseSerializer
+	metrics            *apiMetrics
+	metricsPath        string
+	hypermediaEnvelope bool
+	requestTimeout     time.Duration
+}
+
+// SetRequestTimeout bounds every request's context.RequestContext to timeout,
+// after which in-flight ResourceHandler calls are canceled and the client
+// receives a 504 Gateway Timeout (see sendResponse). A zero timeout, the
+// default, leaves requests bounded only by the client's own cancellation.
+func (r *RestApi) SetRequestTimeout(timeout time.Duration) {
+	r.requestTimeout = timeout
+}
+
+// builtinSerializers associates each built-in ResponseSerializer with the short
+// alias clients can pass as "format" (e.g. "?format=xml") instead of spelling out
+// its media type.
+var builtinSerializers = map[string]ResponseSerializer{
+	"json":    jsonSerializer{},
+	"xml":     xmlSerializer{},
+	"msgpack": msgpackSerializer{},
+	"cbor":    cborSerializer{},
+}
+
+// NewRestApi creates a RestApi with the built-in JSON, XML, MessagePack, and CBOR
+// serializers registered, both under their media types and their short aliases
+// (see builtinSerializers).
+func NewRestApi() *RestApi {
+	api := &RestApi{
+		mux:         http.NewServeMux(),
+		serializers: map[string]ResponseSerializer{},
+	}
+
+	for alias, s := range builtinSerializers {
+		api.serializers[alias] = s
+		for _, mediaType := range s.mediaTypes() {
+			api.serializers[mediaType] = s
+		}
+	}
+
+	return api
+}
+
+// RegisterSerializer associates a ResponseSerializer with mediaType, overriding any
+// existing serializer registered for that type. This allows consumers to add support
+// for additional wire formats or replace the built-in ones.
+func (r *RestApi) RegisterSerializer(mediaType string, s ResponseSerializer) {
+	r.serializers[mediaType] = s
+}
+
+// RegisterResource wires handler's create, read, list, update, delete, and bulk
+// endpoints onto the RestApi's mux, using handler's *URI methods (or the
+// conventional default) to build each route's pattern. The bulk endpoint is the
+// exception: it is always served at defaultBulkURI, whatever ReadListURI
+// returns. The routes always see the
+// RestApi's current configuration, so EnableMetrics, SetRequestTimeout,
+// EnableHypermediaEnvelope, and RegisterSerializer may be called either before or
+// after RegisterResource.
+//
+// If handler also implements StreamingResourceHandler, its list endpoint is served
+// by handleStreamList instead of handleReadList, streaming results as they're
+// produced rather than buffering the full slice.
+func (r *RestApi) RegisterResource(handler ResourceHandler) {
+	h := requestHandler{RestApi: r}
+	links := resourceHandlerLinks(handler)
+
+	r.mux.HandleFunc("POST "+muxPattern(links.collection),
+		h.handleCreate(handler.ResourceName(), links, handler.CreateResource))
+	if streamer, ok := handler.(StreamingResourceHandler); ok {
+		r.mux.HandleFunc("GET "+muxPattern(links.collection), h.handleStreamList(handler.ResourceName(), streamer))
+	} else {
+		r.mux.HandleFunc("GET "+muxPattern(links.collection),
+			h.handleReadList(handler.ResourceName(), links, handler.ReadResourceList))
+	}
+	r.mux.HandleFunc("GET "+muxPattern(links.self),
+		h.handleRead(handler.ResourceName(), links, handler.ReadResource))
+	r.mux.HandleFunc("PUT "+muxPattern(links.edit),
+		h.handleUpdate(handler.ResourceName(), links, handler.UpdateResource))
+	r.mux.HandleFunc("DELETE "+muxPattern(links.delete),
+		h.handleDelete(handler.ResourceName(), links, handler.DeleteResource))
+	r.mux.HandleFunc("POST "+muxPattern(defaultBulkURI(handler.ResourceName())),
+		h.handleBulk(handler.ResourceName(), links, handler))
+}
+
+// responseSerializer returns the ResponseSerializer registered for format, which may
+// be a bare media type ("application/xml"), a short alias ("xml", "json",
+// "msgpack", "cbor" — see builtinSerializers), or a file extension mime.
+// TypeByExtension recognizes, with any parameters of the resulting media type
+// (e.g. "; charset=utf-8") stripped before lookup. It returns an error if no
+// serializer is registered for format.
+func (r *RestApi) responseSerializer(format string) (ResponseSerializer, error) {
+	if format == "" {
+		format = defaultMediaType
+	}
+
+	if s, ok := r.serializers[format]; ok {
+		return s, nil
+	}
+
+	if extType := mime.TypeByExtension("." + format); extType != "" {
+		if mediaType, _, err := mime.ParseMediaType(extType); err == nil {
+			if s, ok := r.serializers[mediaType]; ok {
+				return s, nil
+			}
+		}
+	}
+
+	return nil, fmt.Errorf("no serializer registered for format %q", format)
+}
+
+// requestSerializer returns the ResponseSerializer that should be used to decode a
+// request body based on its Content-Type header, falling back to the default
+// serializer when the header is absent or unrecognized.
+func (r *RestApi) requestSerializer(contentType string) ResponseSerializer {
+	if contentType == "" {
+		return r.serializers[defaultMediaType]
+	}
+
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return r.serializers[defaultMediaType]
+	}
+
+	if s, ok := r.serializers[mediaType]; ok {
+		return s
+	}
+
+	return r.serializers[defaultMediaType]
+}
+
+// negotiateFormat determines the response format for r by first checking the
+// "format" query parameter and then, if absent, parsing the Accept header for the
+// highest q-value media type we have a registered serializer for. It falls back to
+// the default media type if negotiation can't find a match.
+func (r *RestApi) negotiateFormat(req *http.Request, queryFormat string) string {
+	if queryFormat != "" {
+		return queryFormat
+	}
+
+	for _, entry := range parseAccept(req.Header.Get("Accept")) {
+		if entry.mediaType == "*/*" {
+			return defaultMediaType
+		}
+		if _, ok := r.serializers[entry.mediaType]; ok {
+			return entry.mediaType
+		}
+	}
+
+	return defaultMediaType
+}
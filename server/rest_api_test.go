@@ -0,0 +1,53 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go-rest/server/context"
+)
+
+// registerTestHandler is a minimal ResourceHandler, served entirely at the
+// conventional default URI, used to exercise RegisterResource end to end.
+type registerTestHandler struct {
+	BaseResourceHandler
+}
+
+func (registerTestHandler) ResourceName() string { return "widgets" }
+
+func (registerTestHandler) ReadResourceList(ctx context.RequestContext, limit int,
+	version string) ([]Resource, string, error) {
+	return []Resource{map[string]interface{}{"id": "1"}}, "", nil
+}
+
+func (registerTestHandler) ReadResource(ctx context.RequestContext, id string,
+	version string) (Resource, error) {
+	return map[string]interface{}{"id": id, "version": version}, nil
+}
+
+// TestRegisterResourceDefaultURI verifies that a ResourceHandler relying entirely
+// on the conventional default URI (i.e. not overriding any of the *URI methods)
+// can be registered and routed without RegisterResource panicking. The default
+// URI's "{version}" wildcard must occupy its own path segment, since
+// http.ServeMux (Go 1.22+) rejects a wildcard that shares a segment with literal
+// text such as the "v" prefix.
+func TestRegisterResourceDefaultURI(t *testing.T) {
+	api := NewRestApi()
+	api.RegisterResource(registerTestHandler{})
+
+	rec := httptest.NewRecorder()
+	api.mux.ServeHTTP(rec, httptest.NewRequest("GET", "/api/v1/widgets", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("GET /api/v1/widgets status = %d, want %d", rec.Code, http.StatusOK)
+	}
+
+	rec = httptest.NewRecorder()
+	api.mux.ServeHTTP(rec, httptest.NewRequest("GET", "/api/v1/widgets/42", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("GET /api/v1/widgets/42 status = %d, want %d", rec.Code, http.StatusOK)
+	}
+	if link := rec.Header().Get("Link"); link == "" {
+		t.Error(`Link header empty, want self/edit/delete/collection rels`)
+	}
+}
@@ -0,0 +1,247 @@
+package server
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/ugorji/go/codec"
+)
+
+// ResponseSerializer handles marshalling resource data to a particular wire format
+// and writing it to an http.ResponseWriter, as well as unmarshalling request bodies
+// encoded in that format.
+type ResponseSerializer interface {
+	// mediaTypes returns the MIME types this serializer knows how to produce and
+	// consume, e.g. "application/json".
+	mediaTypes() []string
+
+	// decode unmarshals the request body into data.
+	decode(body io.Reader, data *map[string]interface{}) error
+
+	// sendSuccessResponse writes a successResponse to w using the given status code.
+	sendSuccessResponse(w http.ResponseWriter, resp successResponse, status int)
+
+	// sendErrorResponse writes an error to w using the given status code.
+	sendErrorResponse(w http.ResponseWriter, err error, status int)
+}
+
+// jsonSerializer is the default ResponseSerializer, used when no format is specified
+// or negotiation fails to find a match.
+type jsonSerializer struct{}
+
+func (jsonSerializer) mediaTypes() []string {
+	return []string{"application/json"}
+}
+
+func (jsonSerializer) decode(body io.Reader, data *map[string]interface{}) error {
+	return json.NewDecoder(body).Decode(data)
+}
+
+func (jsonSerializer) sendSuccessResponse(w http.ResponseWriter, resp successResponse, status int) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(resp)
+}
+
+func (jsonSerializer) sendErrorResponse(w http.ResponseWriter, err error, status int) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(newErrorResponse(err))
+}
+
+// xmlSerializer marshals and unmarshals resources as XML.
+type xmlSerializer struct{}
+
+func (xmlSerializer) mediaTypes() []string {
+	return []string{"application/xml", "text/xml"}
+}
+
+// decode parses the XML body into a generic map. encoding/xml has no support for
+// decoding directly into map[string]interface{} (it only knows how to populate
+// structs, slices, and a few scalar kinds via reflection), so the element tree is
+// walked token by token instead: each element becomes a map key, repeated sibling
+// elements become a []interface{}, and leaf elements (those with no child
+// elements) become the trimmed string value of their character data.
+func (xmlSerializer) decode(body io.Reader, data *map[string]interface{}) error {
+	d := xml.NewDecoder(body)
+	for {
+		tok, err := d.Token()
+		if err != nil {
+			return err
+		}
+		if _, ok := tok.(xml.StartElement); ok {
+			value, err := decodeXMLElement(d)
+			if err != nil {
+				return err
+			}
+			m, _ := value.(map[string]interface{})
+			*data = m
+			return nil
+		}
+	}
+}
+
+// decodeXMLElement decodes the children of the element whose xml.StartElement was
+// just consumed from d, returning once it reads that element's xml.EndElement. It
+// returns a map[string]interface{} if the element has child elements, or the
+// trimmed string value of its character data if it's a leaf.
+func decodeXMLElement(d *xml.Decoder) (interface{}, error) {
+	children := map[string]interface{}{}
+	var text strings.Builder
+
+	for {
+		tok, err := d.Token()
+		if err != nil {
+			return nil, err
+		}
+
+		switch t := tok.(type) {
+		case xml.StartElement:
+			value, err := decodeXMLElement(d)
+			if err != nil {
+				return nil, err
+			}
+			name := t.Name.Local
+			switch existing := children[name].(type) {
+			case nil:
+				children[name] = value
+			case []interface{}:
+				children[name] = append(existing, value)
+			default:
+				children[name] = []interface{}{existing, value}
+			}
+		case xml.CharData:
+			text.Write(t)
+		case xml.EndElement:
+			if len(children) == 0 {
+				return strings.TrimSpace(text.String()), nil
+			}
+			return children, nil
+		}
+	}
+}
+
+func (xmlSerializer) sendSuccessResponse(w http.ResponseWriter, resp successResponse, status int) {
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(status)
+	xml.NewEncoder(w).Encode(resp)
+}
+
+func (xmlSerializer) sendErrorResponse(w http.ResponseWriter, err error, status int) {
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(status)
+	xml.NewEncoder(w).Encode(newErrorResponse(err))
+}
+
+// msgpackHandle is shared by msgpackSerializer's encode and decode paths.
+// RawToString is set explicitly because the codec.MsgpackHandle zero value
+// defaults it to false, which decodes string fields of a map[string]interface{}
+// as []byte instead of string.
+var msgpackHandle = newMsgpackHandle()
+
+func newMsgpackHandle() *codec.MsgpackHandle {
+	h := &codec.MsgpackHandle{}
+	h.RawToString = true
+	return h
+}
+
+// msgpackSerializer marshals and unmarshals resources as MessagePack.
+type msgpackSerializer struct{}
+
+func (msgpackSerializer) mediaTypes() []string {
+	return []string{"application/msgpack", "application/x-msgpack"}
+}
+
+func (msgpackSerializer) decode(body io.Reader, data *map[string]interface{}) error {
+	return codec.NewDecoder(body, msgpackHandle).Decode(data)
+}
+
+func (msgpackSerializer) sendSuccessResponse(w http.ResponseWriter, resp successResponse, status int) {
+	w.Header().Set("Content-Type", "application/msgpack")
+	w.WriteHeader(status)
+	codec.NewEncoder(w, msgpackHandle).Encode(resp)
+}
+
+func (msgpackSerializer) sendErrorResponse(w http.ResponseWriter, err error, status int) {
+	w.Header().Set("Content-Type", "application/msgpack")
+	w.WriteHeader(status)
+	codec.NewEncoder(w, msgpackHandle).Encode(newErrorResponse(err))
+}
+
+// cborHandle is shared by cborSerializer's encode and decode paths. RawToString
+// is set explicitly for symmetry with msgpackHandle and so decoding doesn't rely
+// on the library's untested default behavior for CBOR's text-string major type.
+var cborHandle = newCborHandle()
+
+func newCborHandle() *codec.CborHandle {
+	h := &codec.CborHandle{}
+	h.RawToString = true
+	return h
+}
+
+// cborSerializer marshals and unmarshals resources as CBOR.
+type cborSerializer struct{}
+
+func (cborSerializer) mediaTypes() []string {
+	return []string{"application/cbor"}
+}
+
+func (cborSerializer) decode(body io.Reader, data *map[string]interface{}) error {
+	return codec.NewDecoder(body, cborHandle).Decode(data)
+}
+
+func (cborSerializer) sendSuccessResponse(w http.ResponseWriter, resp successResponse, status int) {
+	w.Header().Set("Content-Type", "application/cbor")
+	w.WriteHeader(status)
+	codec.NewEncoder(w, cborHandle).Encode(resp)
+}
+
+func (cborSerializer) sendErrorResponse(w http.ResponseWriter, err error, status int) {
+	w.Header().Set("Content-Type", "application/cbor")
+	w.WriteHeader(status)
+	codec.NewEncoder(w, cborHandle).Encode(newErrorResponse(err))
+}
+
+// acceptEntry is a single media range parsed out of an Accept header, along with
+// its q-value.
+type acceptEntry struct {
+	mediaType string
+	q         float64
+}
+
+// parseAccept parses an Accept header value into a list of acceptEntry sorted by
+// descending q-value, the order in which they should be tried during negotiation.
+func parseAccept(header string) []acceptEntry {
+	var entries []acceptEntry
+	for _, part := range strings.Split(header, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		pieces := strings.Split(part, ";")
+		mediaType := strings.TrimSpace(pieces[0])
+		q := 1.0
+		for _, param := range pieces[1:] {
+			param = strings.TrimSpace(param)
+			if strings.HasPrefix(param, "q=") {
+				if parsed, err := strconv.ParseFloat(strings.TrimPrefix(param, "q="), 64); err == nil {
+					q = parsed
+				}
+			}
+		}
+
+		entries = append(entries, acceptEntry{mediaType: mediaType, q: q})
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].q > entries[j].q
+	})
+
+	return entries
+}
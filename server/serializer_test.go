@@ -0,0 +1,168 @@
+package server
+
+import (
+	"bytes"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/ugorji/go/codec"
+)
+
+func TestParseAcceptSortsByQValue(t *testing.T) {
+	got := parseAccept("application/xml;q=0.5, application/json, application/cbor;q=0.9")
+	want := []acceptEntry{
+		{mediaType: "application/json", q: 1.0},
+		{mediaType: "application/cbor", q: 0.9},
+		{mediaType: "application/xml", q: 0.5},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("parseAccept() = %+v, want %+v", got, want)
+	}
+}
+
+func TestNegotiateFormatPrefersQueryParam(t *testing.T) {
+	api := NewRestApi()
+	req := httptest.NewRequest("GET", "/api/v1/widgets", nil)
+	req.Header.Set("Accept", "application/xml")
+
+	if got := api.negotiateFormat(req, "application/cbor"); got != "application/cbor" {
+		t.Errorf("negotiateFormat() = %q, want the query format to win over Accept", got)
+	}
+}
+
+func TestNegotiateFormatFallsBackToAcceptHeader(t *testing.T) {
+	api := NewRestApi()
+	req := httptest.NewRequest("GET", "/api/v1/widgets", nil)
+	req.Header.Set("Accept", "application/xml;q=0.5, application/msgpack;q=0.9")
+
+	if got := api.negotiateFormat(req, ""); got != "application/msgpack" {
+		t.Errorf("negotiateFormat() = %q, want the highest-q registered media type", got)
+	}
+}
+
+func TestNegotiateFormatDefaultsOnNoMatch(t *testing.T) {
+	api := NewRestApi()
+	req := httptest.NewRequest("GET", "/api/v1/widgets", nil)
+	req.Header.Set("Accept", "application/unknown")
+
+	if got := api.negotiateFormat(req, ""); got != defaultMediaType {
+		t.Errorf("negotiateFormat() = %q, want default %q", got, defaultMediaType)
+	}
+}
+
+func TestRequestSerializerUsesContentType(t *testing.T) {
+	api := NewRestApi()
+
+	s := api.requestSerializer("application/xml; charset=utf-8")
+	if _, ok := s.(xmlSerializer); !ok {
+		t.Errorf("requestSerializer(application/xml) = %T, want xmlSerializer", s)
+	}
+
+	s = api.requestSerializer("")
+	if _, ok := s.(jsonSerializer); !ok {
+		t.Errorf("requestSerializer(\"\") = %T, want jsonSerializer", s)
+	}
+}
+
+func TestJSONSerializerDecode(t *testing.T) {
+	var data map[string]interface{}
+	body := strings.NewReader(`{"name":"widget","count":3}`)
+
+	if err := (jsonSerializer{}).decode(body, &data); err != nil {
+		t.Fatalf("decode() err = %v, want nil", err)
+	}
+	if data["name"] != "widget" || data["count"] != 3.0 {
+		t.Errorf("decode() = %+v, want name=widget count=3", data)
+	}
+}
+
+func TestXMLSerializerDecode(t *testing.T) {
+	var data map[string]interface{}
+	body := strings.NewReader(`<widget><name>Widget</name><count>3</count></widget>`)
+
+	if err := (xmlSerializer{}).decode(body, &data); err != nil {
+		t.Fatalf("decode() err = %v, want nil", err)
+	}
+	if data["name"] != "Widget" || data["count"] != "3" {
+		t.Errorf("decode() = %+v, want name=Widget count=3", data)
+	}
+}
+
+func TestXMLSerializerDecodeRepeatedElements(t *testing.T) {
+	var data map[string]interface{}
+	body := strings.NewReader(`<items><item>a</item><item>b</item></items>`)
+
+	if err := (xmlSerializer{}).decode(body, &data); err != nil {
+		t.Fatalf("decode() err = %v, want nil", err)
+	}
+	items, ok := data["item"].([]interface{})
+	if !ok || len(items) != 2 || items[0] != "a" || items[1] != "b" {
+		t.Errorf("decode() item = %+v, want [a b]", data["item"])
+	}
+}
+
+func TestMsgpackSerializerDecode(t *testing.T) {
+	var buf bytes.Buffer
+	if err := codec.NewEncoder(&buf, &codec.MsgpackHandle{}).
+		Encode(map[string]interface{}{"name": "widget", "count": 3}); err != nil {
+		t.Fatalf("failed to encode msgpack fixture: %v", err)
+	}
+
+	var data map[string]interface{}
+	if err := (msgpackSerializer{}).decode(&buf, &data); err != nil {
+		t.Fatalf("decode() err = %v, want nil", err)
+	}
+	if data["name"] != "widget" {
+		t.Errorf("decode() name = %v, want widget", data["name"])
+	}
+}
+
+func TestCBORSerializerDecode(t *testing.T) {
+	var buf bytes.Buffer
+	if err := codec.NewEncoder(&buf, &codec.CborHandle{}).
+		Encode(map[string]interface{}{"name": "widget", "count": 3}); err != nil {
+		t.Fatalf("failed to encode cbor fixture: %v", err)
+	}
+
+	var data map[string]interface{}
+	if err := (cborSerializer{}).decode(&buf, &data); err != nil {
+		t.Fatalf("decode() err = %v, want nil", err)
+	}
+	if data["name"] != "widget" {
+		t.Errorf("decode() name = %v, want widget", data["name"])
+	}
+}
+
+func TestResponseSerializerShortAliases(t *testing.T) {
+	api := NewRestApi()
+
+	tests := []struct {
+		format string
+		want   ResponseSerializer
+	}{
+		{"json", jsonSerializer{}},
+		{"xml", xmlSerializer{}},
+		{"msgpack", msgpackSerializer{}},
+		{"cbor", cborSerializer{}},
+	}
+
+	for _, tt := range tests {
+		s, err := api.responseSerializer(tt.format)
+		if err != nil {
+			t.Errorf("responseSerializer(%q) err = %v, want nil", tt.format, err)
+			continue
+		}
+		if reflect.TypeOf(s) != reflect.TypeOf(tt.want) {
+			t.Errorf("responseSerializer(%q) = %T, want %T", tt.format, s, tt.want)
+		}
+	}
+}
+
+func TestResponseSerializerUnknownFormat(t *testing.T) {
+	api := NewRestApi()
+	if _, err := api.responseSerializer("yaml"); err == nil {
+		t.Error("responseSerializer(\"yaml\") err = nil, want an error")
+	}
+}
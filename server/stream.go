@@ -0,0 +1,198 @@
+package server
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+
+	"go-rest/server/context"
+)
+
+// streamFormat identifies the wire format used to stream a ReadResourceList
+// response, selected via the "format" query parameter.
+type streamFormat string
+
+const (
+	streamNDJSON    streamFormat = "ndjson"
+	streamJSONArray streamFormat = "json-array"
+	streamCSV       streamFormat = "csv"
+)
+
+// defaultStreamFormat is used when the "format" query parameter doesn't name a
+// supported streaming mode.
+const defaultStreamFormat = streamJSONArray
+
+// StreamingResourceHandler is implemented by ResourceHandlers that can produce a
+// ReadResourceList response incrementally instead of buffering the whole result set
+// in memory. StreamResourceList must send each Resource on out as it's produced and
+// return once done; it must also stop producing and return as soon as ctx is done
+// so a slow or disconnected client doesn't pin server memory.
+type StreamingResourceHandler interface {
+	StreamResourceList(ctx context.RequestContext, limit int, cursor string, version string,
+		out chan<- Resource) error
+}
+
+// handleStreamList returns a HandlerFunc which streams handler's ReadResourceList
+// results to the client as they're produced by StreamResourceList, flushing after
+// every resource instead of buffering the full slice before serializing. The wire
+// format is selected by the "format" query parameter ("ndjson", "json-array", or
+// "csv"; see newStreamEncoder), defaulting to a streamed JSON array. If metrics are
+// enabled, the request is instrumented under resourceName.
+func (h requestHandler) handleStreamList(resourceName string, handler StreamingResourceHandler) http.HandlerFunc {
+	return h.instrument(resourceName, "GET", func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := h.newContext(r)
+		defer cancel()
+
+		flusher, ok := w.(http.Flusher)
+		if !ok {
+			jsonSerializer{}.sendErrorResponse(w,
+				fmt.Errorf("streaming unsupported by the response writer"), http.StatusNotImplemented)
+			return
+		}
+
+		enc := newStreamEncoder(w, streamFormat(r.URL.Query().Get("format")))
+
+		out := make(chan Resource)
+		errc := make(chan error, 1)
+		go func() {
+			defer close(out)
+			errc <- handler.StreamResourceList(ctx, ctx.Limit(), ctx.Cursor(), ctx.Version(), out)
+		}()
+
+		enc.writeHeader()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case resource, open := <-out:
+				if !open {
+					// The producer goroutine sends to errc before closing out, so
+					// it's always ready to receive from here. Only write the
+					// footer on success; omitting it on error lets a well-behaved
+					// client detect the response was cut short instead of reading
+					// it as a complete, empty-tailed result.
+					if err := <-errc; err != nil {
+						return
+					}
+					enc.writeFooter()
+					flusher.Flush()
+					return
+				}
+				if err := enc.writeResource(resource); err != nil {
+					return
+				}
+				flusher.Flush()
+			}
+		}
+	})
+}
+
+// streamEncoder incrementally writes Resources to an http.ResponseWriter in a
+// single wire format, one at a time, so a caller can flush after each write.
+type streamEncoder interface {
+	writeHeader()
+	writeResource(resource Resource) error
+	writeFooter()
+}
+
+// newStreamEncoder returns the streamEncoder for format, setting the appropriate
+// Content-Type header on w. An unrecognized format falls back to defaultStreamFormat.
+func newStreamEncoder(w http.ResponseWriter, format streamFormat) streamEncoder {
+	switch format {
+	case streamNDJSON:
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		return &ndjsonEncoder{w: w}
+	case streamCSV:
+		w.Header().Set("Content-Type", "text/csv")
+		return &csvStreamEncoder{w: csv.NewWriter(w)}
+	default:
+		w.Header().Set("Content-Type", "application/json")
+		return &jsonArrayEncoder{w: w}
+	}
+}
+
+// ndjsonEncoder writes one JSON-encoded resource per line.
+type ndjsonEncoder struct {
+	w io.Writer
+}
+
+func (e *ndjsonEncoder) writeHeader() {}
+
+func (e *ndjsonEncoder) writeResource(resource Resource) error {
+	return json.NewEncoder(e.w).Encode(resource)
+}
+
+func (e *ndjsonEncoder) writeFooter() {}
+
+// jsonArrayEncoder writes resources as elements of a single top-level JSON array,
+// emitting each element as it arrives rather than marshalling the whole slice.
+type jsonArrayEncoder struct {
+	w     io.Writer
+	wrote bool
+}
+
+func (e *jsonArrayEncoder) writeHeader() {
+	fmt.Fprint(e.w, "[")
+}
+
+func (e *jsonArrayEncoder) writeResource(resource Resource) error {
+	if e.wrote {
+		fmt.Fprint(e.w, ",")
+	}
+	e.wrote = true
+	return json.NewEncoder(e.w).Encode(resource)
+}
+
+func (e *jsonArrayEncoder) writeFooter() {
+	fmt.Fprint(e.w, "]")
+}
+
+// csvStreamEncoder writes resources as CSV rows, deriving the header from the
+// field names of the first resource. Resources that aren't a map[string]interface{}
+// are written as a single "value" column.
+type csvStreamEncoder struct {
+	w      *csv.Writer
+	header []string
+}
+
+func (e *csvStreamEncoder) writeHeader() {}
+
+func (e *csvStreamEncoder) writeResource(resource Resource) error {
+	fields, ok := resource.(map[string]interface{})
+	if !ok {
+		if e.header == nil {
+			e.header = []string{"value"}
+			if err := e.w.Write(e.header); err != nil {
+				return err
+			}
+		}
+		defer e.w.Flush()
+		return e.w.Write([]string{fmt.Sprintf("%v", resource)})
+	}
+
+	if e.header == nil {
+		e.header = make([]string, 0, len(fields))
+		for name := range fields {
+			e.header = append(e.header, name)
+		}
+		sort.Strings(e.header)
+		if err := e.w.Write(e.header); err != nil {
+			return err
+		}
+	}
+
+	row := make([]string, len(e.header))
+	for i, name := range e.header {
+		row[i] = fmt.Sprintf("%v", fields[name])
+	}
+
+	defer e.w.Flush()
+	return e.w.Write(row)
+}
+
+func (e *csvStreamEncoder) writeFooter() {
+	e.w.Flush()
+}
@@ -0,0 +1,162 @@
+package server
+
+import (
+	"bytes"
+	"errors"
+	"net/http/httptest"
+	"testing"
+
+	"go-rest/server/context"
+)
+
+func TestJSONArrayEncoderWritesIncrementally(t *testing.T) {
+	var buf bytes.Buffer
+	enc := &jsonArrayEncoder{w: &buf}
+
+	enc.writeHeader()
+	if err := enc.writeResource(map[string]interface{}{"id": "1"}); err != nil {
+		t.Fatalf("writeResource() err = %v, want nil", err)
+	}
+	if err := enc.writeResource(map[string]interface{}{"id": "2"}); err != nil {
+		t.Fatalf("writeResource() err = %v, want nil", err)
+	}
+	enc.writeFooter()
+
+	want := "[{\"id\":\"1\"}\n,{\"id\":\"2\"}\n]"
+	if buf.String() != want {
+		t.Errorf("buf = %q, want %q", buf.String(), want)
+	}
+}
+
+func TestNewStreamEncoderContentType(t *testing.T) {
+	tests := []struct {
+		format      streamFormat
+		contentType string
+	}{
+		{streamNDJSON, "application/x-ndjson"},
+		{streamCSV, "text/csv"},
+		{streamJSONArray, "application/json"},
+		{"", "application/json"},
+	}
+
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		newStreamEncoder(rec, tt.format)
+		if got := rec.Header().Get("Content-Type"); got != tt.contentType {
+			t.Errorf("newStreamEncoder(%q) Content-Type = %q, want %q", tt.format, got, tt.contentType)
+		}
+	}
+}
+
+// stubStreamingHandler reports resources then returns err, simulating a
+// StreamingResourceHandler that fails partway through.
+type stubStreamingHandler struct {
+	BaseResourceHandler
+	resources []Resource
+	err       error
+}
+
+func (s stubStreamingHandler) StreamResourceList(ctx context.RequestContext, limit int,
+	cursor, version string, out chan<- Resource) error {
+	for _, r := range s.resources {
+		out <- r
+	}
+	return s.err
+}
+
+func TestHandleStreamListOmitsFooterOnError(t *testing.T) {
+	h := requestHandler{RestApi: &RestApi{}}
+	handler := stubStreamingHandler{
+		resources: []Resource{map[string]interface{}{"id": "1"}},
+		err:       errors.New("boom"),
+	}
+
+	req := httptest.NewRequest("GET", "/api/v1/widgets?format=json-array", nil)
+	rec := httptest.NewRecorder()
+
+	h.handleStreamList("widgets", handler)(rec, req)
+
+	// The resource written before the error is kept, but the closing "]" is not
+	// written, so the client can tell the array was cut short.
+	if rec.Body.Len() == 0 {
+		t.Error("body is empty, want the one resource written before the error")
+	}
+	want := "[{\"id\":\"1\"}\n"
+	if rec.Body.String() != want {
+		t.Errorf("body = %q, want %q without the closing footer", rec.Body.String(), want)
+	}
+}
+
+func TestHandleStreamListWritesFooterOnSuccess(t *testing.T) {
+	h := requestHandler{RestApi: &RestApi{}}
+	handler := stubStreamingHandler{resources: []Resource{map[string]interface{}{"id": "1"}}}
+
+	req := httptest.NewRequest("GET", "/api/v1/widgets?format=json-array", nil)
+	rec := httptest.NewRecorder()
+
+	h.handleStreamList("widgets", handler)(rec, req)
+
+	want := "[{\"id\":\"1\"}\n]"
+	if rec.Body.String() != want {
+		t.Errorf("body = %q, want %q", rec.Body.String(), want)
+	}
+}
+
+// TestHandleStreamListWithMetricsEnabled guards against instrument's
+// *statusRecorder shadowing the http.Flusher the underlying httptest.Recorder
+// implements: if statusRecorder doesn't forward Flush, handleStreamList's
+// w.(http.Flusher) check fails and every streaming request falls back to the
+// "streaming unsupported" 501 as soon as EnableMetrics is on.
+func TestHandleStreamListWithMetricsEnabled(t *testing.T) {
+	api := NewRestApi()
+	api.EnableMetrics("test")
+	h := requestHandler{RestApi: api}
+	handler := stubStreamingHandler{resources: []Resource{map[string]interface{}{"id": "1"}}}
+
+	req := httptest.NewRequest("GET", "/api/v1/widgets?format=json-array", nil)
+	rec := httptest.NewRecorder()
+
+	h.handleStreamList("widgets", handler)(rec, req)
+
+	want := "[{\"id\":\"1\"}\n]"
+	if rec.Body.String() != want {
+		t.Errorf("body = %q, want %q; streaming should still work with metrics enabled", rec.Body.String(), want)
+	}
+}
+
+func TestHandleStreamListCSV(t *testing.T) {
+	h := requestHandler{RestApi: &RestApi{}}
+	handler := stubStreamingHandler{resources: []Resource{
+		map[string]interface{}{"id": "1", "name": "widget"},
+		map[string]interface{}{"id": "2", "name": "gadget"},
+	}}
+
+	req := httptest.NewRequest("GET", "/api/v1/widgets?format=csv", nil)
+	rec := httptest.NewRecorder()
+
+	h.handleStreamList("widgets", handler)(rec, req)
+
+	if got := rec.Header().Get("Content-Type"); got != "text/csv" {
+		t.Errorf("Content-Type = %q, want %q", got, "text/csv")
+	}
+
+	want := "id,name\n1,widget\n2,gadget\n"
+	if rec.Body.String() != want {
+		t.Errorf("body = %q, want %q", rec.Body.String(), want)
+	}
+}
+
+func TestHandleStreamListCSVNonMapResource(t *testing.T) {
+	h := requestHandler{RestApi: &RestApi{}}
+	handler := stubStreamingHandler{resources: []Resource{"widget", "gadget"}}
+
+	req := httptest.NewRequest("GET", "/api/v1/widgets?format=csv", nil)
+	rec := httptest.NewRecorder()
+
+	h.handleStreamList("widgets", handler)(rec, req)
+
+	want := "value\nwidget\ngadget\n"
+	if rec.Body.String() != want {
+		t.Errorf("body = %q, want %q", rec.Body.String(), want)
+	}
+}